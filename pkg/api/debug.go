@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime/debug"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xmtp/xmtp-node-go/pkg/tracing"
+	"go.uber.org/zap"
+)
+
+// startDebug optionally mounts a debug/introspection HTTP server, gated by
+// Config.Options.EnableDebugEndpoints and bound to its own listener
+// (DebugAddress/DebugPort) so it can't be reached via the public
+// grpc-gateway listener in startHTTP. It exposes pprof, expvar, build info,
+// a redacted config dump, and the set of registered gRPC services, for
+// operators to inspect a running node without needing a shell on the box.
+func (s *Server) startDebug() error {
+	if !s.Config.Options.EnableDebugEndpoints {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/build", s.handleDebugBuild)
+	mux.HandleFunc("/debug/config", s.handleDebugConfig)
+	mux.HandleFunc("/debug/grpc", s.handleDebugGRPC)
+
+	addr := addrString(s.DebugAddress, s.DebugPort)
+	var err error
+	s.debugListener, err = net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "creating debug listener")
+	}
+
+	server := http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	tracing.GoPanicWrap(s.ctx, &s.wg, "debug", func(ctx context.Context) {
+		s.Log.Info("serving debug", zap.String("address", s.debugListener.Addr().String()))
+		err := server.Serve(s.debugListener)
+		if err != nil && err != http.ErrServerClosed && !isErrUseOfClosedConnection(err) {
+			s.Log.Error("serving debug", zap.Error(err))
+		}
+	})
+
+	return nil
+}
+
+func (s *Server) handleDebugBuild(w http.ResponseWriter, r *http.Request) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		http.Error(w, "build info unavailable", http.StatusNotFound)
+		return
+	}
+
+	settings := make(map[string]string, len(info.Settings))
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+
+	writeDebugJSON(w, struct {
+		GoVersion string            `json:"go_version"`
+		Module    string            `json:"main_module"`
+		Version   string            `json:"version"`
+		Settings  map[string]string `json:"settings"`
+	}{
+		GoVersion: info.GoVersion,
+		Module:    info.Main.Path,
+		Version:   info.Main.Version,
+		Settings:  settings,
+	})
+}
+
+func (s *Server) handleDebugConfig(w http.ResponseWriter, r *http.Request) {
+	writeDebugJSON(w, redactConfig(s.Config))
+}
+
+func (s *Server) handleDebugGRPC(w http.ResponseWriter, r *http.Request) {
+	services := make(map[string][]string)
+	for name, info := range s.grpcServer.GetServiceInfo() {
+		methods := make([]string, 0, len(info.Methods))
+		for _, method := range info.Methods {
+			methods = append(methods, method.Name)
+		}
+		services[name] = methods
+	}
+	writeDebugJSON(w, services)
+}
+
+func writeDebugJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// sensitiveConfigKeyParts are substrings (checked case-insensitively) of
+// config field names whose values are replaced with "[redacted]" before
+// being served from /debug/config.
+var sensitiveConfigKeyParts = []string{"key", "secret", "token", "password", "credential"}
+
+// redactConfig renders cfg as a JSON-able map with anything that looks like
+// a credential stripped out, so the debug listener is safe to expose even
+// though Config itself may embed API keys and private key material.
+func redactConfig(cfg *Config) map[string]interface{} {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	var out map[string]interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	redactSensitiveFields(out)
+	return out
+}
+
+func redactSensitiveFields(v map[string]interface{}) {
+	for key, val := range v {
+		if isSensitiveConfigKey(key) {
+			v[key] = "[redacted]"
+			continue
+		}
+		v[key] = redactValue(val)
+	}
+}
+
+// redactValue recurses into nested objects and arrays so a secret buried in
+// a slice field (e.g. a list of per-upstream configs, each carrying its own
+// API key) is redacted the same as a top-level one.
+func redactValue(val interface{}) interface{} {
+	switch typed := val.(type) {
+	case map[string]interface{}:
+		redactSensitiveFields(typed)
+		return typed
+	case []interface{}:
+		for i, elem := range typed {
+			typed[i] = redactValue(elem)
+		}
+		return typed
+	default:
+		return val
+	}
+}
+
+func isSensitiveConfigKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, part := range sensitiveConfigKeyParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+
+	apierrors "github.com/xmtp/xmtp-node-go/pkg/api/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorInterceptor converts errors returned by messagev1/messagev3 handlers
+// into gRPC statuses via apierrors.FromError: an XMTPError becomes a typed
+// status carrying a structured google.rpc.ErrorInfo (see pkg/api/errors),
+// any other non-status error a plain Internal status without one. It's
+// installed innermost in Server.startGRPC's interceptor chain, directly
+// around the handler (the authorizers sit outside it, so errors they return
+// don't pass through it), so that its conversion runs ahead of
+// TelemetryInterceptor.record on the way
+// back out and operators can log/alert on the structured reason rather than
+// only the gRPC code.
+type ErrorInterceptor struct{}
+
+// NewErrorInterceptor constructs an ErrorInterceptor.
+func NewErrorInterceptor() *ErrorInterceptor {
+	return &ErrorInterceptor{}
+}
+
+func (ei *ErrorInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		res, err := handler(ctx, req)
+		if err != nil {
+			err = apierrors.FromError(err).Err()
+		}
+		return res, err
+	}
+}
+
+func (ei *ErrorInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		err := handler(srv, stream)
+		if err != nil {
+			err = apierrors.FromError(err).Err()
+		}
+		return err
+	}
+}
+
+// unwrapErrorsUnaryClientInterceptor and unwrapErrorsStreamClientInterceptor
+// are the client-side counterpart of ErrorInterceptor, installed on the
+// internal connection Server.dialGRPC opens for the grpc-gateway loop. They
+// rehydrate the structured ErrorInfo attached by ErrorInterceptor back into
+// a rich XMTPError, so the gateway handlers can type-switch on it instead of
+// re-parsing status details.
+func unwrapErrorsUnaryClientInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	return unwrapError(invoker(ctx, method, req, reply, cc, opts...))
+}
+
+func unwrapErrorsStreamClientInterceptor(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return stream, unwrapError(err)
+	}
+	return &errorUnwrappingClientStream{ClientStream: stream}, nil
+}
+
+type errorUnwrappingClientStream struct {
+	grpc.ClientStream
+}
+
+func (s *errorUnwrappingClientStream) RecvMsg(m interface{}) error {
+	return unwrapError(s.ClientStream.RecvMsg(m))
+}
+
+func unwrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if st, ok := status.FromError(err); ok {
+		return apierrors.ToError(st)
+	}
+	return err
+}
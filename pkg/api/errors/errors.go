@@ -0,0 +1,205 @@
+// Package errors defines XMTP-specific error types carrying enough structure
+// to populate a google.rpc.ErrorInfo detail on the wire, so that gRPC
+// clients — and the operators watching TelemetryInterceptor's logs — can
+// distinguish failure modes without parsing status messages.
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain identifies this service as the origin of a structured error,
+// per the google.rpc.ErrorInfo convention.
+const errorDomain = "xmtp.chat"
+
+// XMTPError is implemented by every error type in this package. Handlers can
+// simply `return err` and have Server.startGRPC's error interceptor (see
+// api.ErrorInterceptor) attach the right wire representation via AsGRPC.
+type XMTPError interface {
+	error
+	AsGRPC() *status.Status
+	Reason() string
+	Metadata() map[string]string
+}
+
+// baseError is the concrete type behind every constructor in this package.
+type baseError struct {
+	code     codes.Code
+	reason   string
+	message  string
+	metadata map[string]string
+}
+
+func (e *baseError) Error() string { return e.message }
+
+func (e *baseError) Reason() string { return e.reason }
+
+func (e *baseError) Metadata() map[string]string { return e.metadata }
+
+// GRPCStatus lets grpc-go's status.FromError recognize baseError directly,
+// so the structured ErrorInfo detail reaches the wire even for a handler
+// error that never passes through api.ErrorInterceptor (e.g. one returned
+// by an authorizer that short-circuits ahead of it).
+func (e *baseError) GRPCStatus() *status.Status {
+	return e.AsGRPC()
+}
+
+func (e *baseError) AsGRPC() *status.Status {
+	st := status.New(e.code, e.message)
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   e.reason,
+		Domain:   errorDomain,
+		Metadata: e.metadata,
+	})
+	if err != nil {
+		// A well-formed ErrorInfo should never fail to attach; fall back to
+		// the plain status rather than losing the error entirely.
+		return st
+	}
+	return withDetails
+}
+
+// ErrRateLimited indicates the caller exceeded their configured rate limit.
+// retryAfterMs is surfaced to the client so it can back off intelligently
+// instead of guessing.
+func ErrRateLimited(retryAfterMs int64) XMTPError {
+	return &baseError{
+		code:    codes.ResourceExhausted,
+		reason:  "RATE_LIMITED",
+		message: "rate limit exceeded",
+		metadata: map[string]string{
+			"retry_after_ms": strconv.FormatInt(retryAfterMs, 10),
+		},
+	}
+}
+
+// ErrUnauthenticated indicates the request did not carry valid credentials.
+func ErrUnauthenticated(reason string) XMTPError {
+	return &baseError{
+		code:    codes.Unauthenticated,
+		reason:  "UNAUTHENTICATED",
+		message: reason,
+	}
+}
+
+// ErrInvalidTopic indicates the request referenced a malformed or
+// disallowed topic.
+func ErrInvalidTopic(topic string) XMTPError {
+	return &baseError{
+		code:    codes.InvalidArgument,
+		reason:  "INVALID_TOPIC",
+		message: fmt.Sprintf("invalid topic: %s", topic),
+		metadata: map[string]string{
+			"topic": topic,
+		},
+	}
+}
+
+// ErrPayloadTooLarge indicates the request payload exceeded the configured
+// maximum message size.
+func ErrPayloadTooLarge(sizeBytes, maxBytes int) XMTPError {
+	return &baseError{
+		code:    codes.InvalidArgument,
+		reason:  "PAYLOAD_TOO_LARGE",
+		message: fmt.Sprintf("payload of %d bytes exceeds maximum of %d bytes", sizeBytes, maxBytes),
+		metadata: map[string]string{
+			"size_bytes": strconv.Itoa(sizeBytes),
+			"max_bytes":  strconv.Itoa(maxBytes),
+		},
+	}
+}
+
+// ErrMlsValidation indicates an MLS message or group state failed
+// validation.
+func ErrMlsValidation(reason string) XMTPError {
+	return &baseError{
+		code:    codes.InvalidArgument,
+		reason:  "MLS_VALIDATION",
+		message: reason,
+	}
+}
+
+// FromError converts any error returned by an RPC handler into a
+// *status.Status, attaching a structured ErrorInfo detail when err is an
+// XMTPError. Errors that are already gRPC statuses (or wrap one) pass
+// through unchanged; anything else is reported as an Internal error whose
+// message is err.Error().
+func FromError(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+	var xerr XMTPError
+	if stderrors.As(err, &xerr) {
+		return xerr.AsGRPC()
+	}
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+	return status.New(codes.Internal, err.Error())
+}
+
+// ToError reconstructs a rich XMTPError from a *status.Status that carries a
+// google.rpc.ErrorInfo detail in this package's domain, for use on the
+// client side (see Server.dialGRPC). Statuses without a matching ErrorInfo
+// are returned via status.Status.Err() unchanged.
+func ToError(st *status.Status) error {
+	if st == nil || st.Code() == codes.OK {
+		return nil
+	}
+	info, ok := errorInfoFromStatus(st)
+	if !ok {
+		return st.Err()
+	}
+	return &baseError{
+		code:     st.Code(),
+		reason:   info.Reason,
+		message:  st.Message(),
+		metadata: info.Metadata,
+	}
+}
+
+// errorInfoFromStatus finds the ErrorInfo detail this package attached to
+// st, if any.
+func errorInfoFromStatus(st *status.Status) (*errdetails.ErrorInfo, bool) {
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Domain == errorDomain {
+			return info, true
+		}
+	}
+	return nil, false
+}
+
+// Reason returns the ErrorInfo reason and metadata carried by err, if it is
+// (or wraps) an XMTPError, for structured logging and alerting. See
+// api.TelemetryInterceptor.record.
+func Reason(err error) (reason string, metadata map[string]string, ok bool) {
+	var xerr XMTPError
+	if stderrors.As(err, &xerr) {
+		return xerr.Reason(), xerr.Metadata(), true
+	}
+	return "", nil, false
+}
+
+// ReasonFromStatus is Reason's counterpart for callers that already hold a
+// *status.Status (e.g. TelemetryInterceptor.record, which converts err via
+// status.FromError before logging). By the time an error reaches the
+// server-side telemetry interceptor it has typically already been passed
+// through ErrorInterceptor, which replaces the original XMTPError with a
+// plain gRPC status error — so inspecting the Go error itself no longer
+// finds the ErrorInfo detail, only the status it was serialized into does.
+func ReasonFromStatus(st *status.Status) (reason string, metadata map[string]string, ok bool) {
+	if st == nil {
+		return "", nil, false
+	}
+	info, ok := errorInfoFromStatus(st)
+	if !ok {
+		return "", nil, false
+	}
+	return info.Reason, info.Metadata, true
+}
@@ -0,0 +1,83 @@
+package errors
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+// TestRoundTrip verifies that the Reason/Metadata attached by a constructor
+// survive a full server -> wire -> client round trip: FromError produces
+// the *status.Status an RPC actually sends, and ToError (the client-side
+// counterpart) must recover the same Reason/Metadata from it. This is what
+// TelemetryInterceptor.record and dialGRPC's unwrap interceptor both rely
+// on; a regression here means error_reason/error_detail_* silently stop
+// showing up in logs and the gateway loop stops seeing rich errors.
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		err  XMTPError
+		code codes.Code
+	}{
+		{"rate limited", ErrRateLimited(1500), codes.ResourceExhausted},
+		{"unauthenticated", ErrUnauthenticated("missing credentials"), codes.Unauthenticated},
+		{"invalid topic", ErrInvalidTopic("/bad/topic"), codes.InvalidArgument},
+		{"payload too large", ErrPayloadTooLarge(2048, 1024), codes.InvalidArgument},
+		{"mls validation", ErrMlsValidation("bad commit"), codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := FromError(tt.err)
+			if st.Code() != tt.code {
+				t.Fatalf("FromError: expected code %s, got %s", tt.code, st.Code())
+			}
+
+			reconstructed := ToError(st)
+			var xerr XMTPError
+			if !stderrors.As(reconstructed, &xerr) {
+				t.Fatalf("ToError did not return an XMTPError: %v", reconstructed)
+			}
+
+			if xerr.Reason() != tt.err.Reason() {
+				t.Errorf("Reason: expected %q, got %q", tt.err.Reason(), xerr.Reason())
+			}
+			for k, v := range tt.err.Metadata() {
+				if xerr.Metadata()[k] != v {
+					t.Errorf("Metadata[%q]: expected %q, got %q", k, v, xerr.Metadata()[k])
+				}
+			}
+
+			// ReasonFromStatus is what TelemetryInterceptor.record actually
+			// calls, since by the time it runs err has usually already been
+			// serialized into a *status.Status by ErrorInterceptor.
+			reason, metadata, ok := ReasonFromStatus(st)
+			if !ok {
+				t.Fatal("ReasonFromStatus: expected ok=true")
+			}
+			if reason != tt.err.Reason() {
+				t.Errorf("ReasonFromStatus reason: expected %q, got %q", tt.err.Reason(), reason)
+			}
+			for k, v := range tt.err.Metadata() {
+				if metadata[k] != v {
+					t.Errorf("ReasonFromStatus metadata[%q]: expected %q, got %q", k, v, metadata[k])
+				}
+			}
+		})
+	}
+}
+
+// TestReasonDoesNotSeeWireOnlyErrors documents the gap ReasonFromStatus
+// closes: Reason (which inspects the Go error via errors.As) can't recover
+// anything once an XMTPError has been serialized into a plain
+// *status.Status and back via status.Err(), because that value implements
+// only Error()/GRPCStatus(), not XMTPError.
+func TestReasonDoesNotSeeWireOnlyErrors(t *testing.T) {
+	wireErr := FromError(ErrInvalidTopic("/bad/topic")).Err()
+
+	if _, _, ok := Reason(wireErr); ok {
+		t.Fatal("Reason unexpectedly recovered details from a plain status error; use ReasonFromStatus for wire errors")
+	}
+}
+
@@ -0,0 +1,443 @@
+package api
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"github.com/pkg/errors"
+	apierrors "github.com/xmtp/xmtp-node-go/pkg/api/errors"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// defaultJWTKeyCacheTTL is how long a fetched JWKS is trusted before being
+// refetched, absent an explicit JWTAuthnConfig.KeyCacheTTL.
+const defaultJWTKeyCacheTTL = 30 * time.Second
+
+// minJWKSRefreshInterval bounds how often keyFunc will hit the network on a
+// `kid` cache miss. Without it, a flood of tokens carrying distinct unknown
+// `kid`s (cheap to mint: iss/aud are checked on unverified claims before
+// keyFunc ever runs) would trigger a synchronous JWKS fetch per request,
+// hammering the JWKS endpoint and serializing the whole auth path behind
+// each fetch's latency.
+const minJWKSRefreshInterval = 5 * time.Second
+
+type m2mContextKey string
+
+const (
+	m2mSubjectContextKey m2mContextKey = "m2m_subject"
+	m2mScopesContextKey  m2mContextKey = "m2m_scopes"
+)
+
+// M2MSubject returns the `sub` claim of the machine-to-machine caller
+// authenticated by JWTAuthorizer, if any.
+func M2MSubject(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(m2mSubjectContextKey).(string)
+	return subject, ok
+}
+
+// M2MScopes returns the scopes granted to the machine-to-machine caller
+// authenticated by JWTAuthorizer, if any.
+func M2MScopes(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(m2mScopesContextKey).([]string)
+	return scopes, ok
+}
+
+// JWTAuthnConfig configures JWTAuthorizer.
+type JWTAuthnConfig struct {
+	// Issuer is the `iss` claim JWTAuthorizer looks for to recognize an M2M
+	// token; tokens with a different issuer (including wallet-signed ones)
+	// are left for WalletAuthorizer to handle.
+	Issuer string
+	// Audience is the `aud` claim every M2M token must carry.
+	Audience string
+	// JWKSURL is fetched, and cached for KeyCacheTTL, to validate token
+	// signatures.
+	JWKSURL string
+	// AllowedSubjects restricts which `sub` claims may authenticate. Empty
+	// means any subject from Issuer is accepted.
+	AllowedSubjects []string
+	// MethodScopes maps a full gRPC method name (e.g.
+	// "/xmtp.message_api.v3.MlsApi/PublishToGroup") to the scopes a token
+	// must carry at least one of to call it. A method absent from this map
+	// is allowed for any authenticated M2M caller.
+	MethodScopes map[string][]string
+	// KeyCacheTTL is how long a fetched JWKS is cached before being
+	// refreshed; a `kid` cache miss also triggers a refetch, at most once
+	// per minJWKSRefreshInterval.
+	// Defaults to defaultJWTKeyCacheTTL.
+	KeyCacheTTL time.Duration
+	// RateLimitPerSecond caps the sustained rate of authenticated requests
+	// per `sub`, beyond which authenticate returns apierrors.ErrRateLimited.
+	// Zero (the default) disables per-subject rate limiting.
+	RateLimitPerSecond float64
+	// RateLimitBurst is the token bucket size backing RateLimitPerSecond.
+	// Defaults to 1 if RateLimitPerSecond is set and this is zero.
+	RateLimitBurst int
+
+	Log *zap.Logger
+}
+
+// JWTAuthorizer validates bearer tokens from server-to-server callers
+// (indexers, delivery workers) against a configured OIDC/M2M issuer, as an
+// alternative to WalletAuthorizer's wallet-signature scheme. It's installed
+// ahead of WalletAuthorizer in Server.startGRPC and short-circuits only when
+// the token's `iss`/`aud` match JWTAuthnConfig, so wallet-authenticated
+// traffic is unaffected.
+type JWTAuthorizer struct {
+	config JWTAuthnConfig
+	log    *zap.Logger
+	client *http.Client
+
+	mu                 sync.Mutex
+	keys               map[string]*rsa.PublicKey
+	fetchedAt          time.Time
+	lastRefreshAttempt time.Time
+
+	rlMu     sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewJWTAuthorizer constructs a JWTAuthorizer from config.
+func NewJWTAuthorizer(config *JWTAuthnConfig) *JWTAuthorizer {
+	ttl := config.KeyCacheTTL
+	if ttl <= 0 {
+		ttl = defaultJWTKeyCacheTTL
+	}
+	config.KeyCacheTTL = ttl
+	if config.RateLimitPerSecond > 0 && config.RateLimitBurst <= 0 {
+		config.RateLimitBurst = 1
+	}
+
+	return &JWTAuthorizer{
+		config:   *config,
+		log:      config.Log,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		keys:     map[string]*rsa.PublicKey{},
+		limiters: map[string]*rate.Limiter{},
+	}
+}
+
+// UnaryWithFallback returns a unary interceptor that authenticates M2M
+// tokens itself and, for any request that isn't an M2M token for its
+// configured issuer, delegates entirely to fallback (typically
+// WalletAuthorizer.Unary()) — a true short-circuit between the two auth
+// schemes rather than chaining both checks on every request. With no
+// fallback (JWT-only mode), a request that doesn't carry a recognized M2M
+// token is rejected outright rather than reaching the handler
+// unauthenticated.
+func (a *JWTAuthorizer) UnaryWithFallback(fallback grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		newCtx, handled, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		if !handled {
+			if fallback != nil {
+				return fallback(ctx, req, info, handler)
+			}
+			return nil, errMissingM2MToken
+		}
+		return handler(newCtx, req)
+	}
+}
+
+// StreamWithFallback is UnaryWithFallback's streaming counterpart.
+func (a *JWTAuthorizer) StreamWithFallback(fallback grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		newCtx, handled, err := a.authenticate(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		if !handled {
+			if fallback != nil {
+				return fallback(srv, stream, info, handler)
+			}
+			return errMissingM2MToken
+		}
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = newCtx
+		return handler(srv, wrapped)
+	}
+}
+
+// errMissingM2MToken is returned by {Unary,Stream}WithFallback in JWT-only
+// mode (no WalletAuthorizer fallback configured) when the request doesn't
+// carry a recognized M2M token, so the absence of a bearer token can't be
+// mistaken for an unauthenticated pass-through.
+var errMissingM2MToken = apierrors.ErrUnauthenticated("missing or unrecognized m2m bearer token")
+
+// authenticate inspects the incoming token, if any. handled is false when
+// the request isn't an M2M token for our configured issuer, meaning the
+// caller should fall through to WalletAuthorizer. Once a token's `iss`/`aud`
+// match, authenticate commits to the M2M path: any further validation
+// failure is returned as an error rather than falling through.
+func (a *JWTAuthorizer) authenticate(ctx context.Context, fullMethod string) (context.Context, bool, error) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		return ctx, false, nil
+	}
+
+	unverified, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return ctx, false, nil
+	}
+	claims, ok := unverified.Claims.(jwt.MapClaims)
+	if !ok {
+		return ctx, false, nil
+	}
+	if iss, _ := claims.GetIssuer(); iss != a.config.Issuer {
+		return ctx, false, nil
+	}
+	aud, _ := claims.GetAudience()
+	if !containsString(aud, a.config.Audience) {
+		return ctx, false, nil
+	}
+
+	parsed, err := jwt.Parse(token, a.keyFunc,
+		jwt.WithIssuer(a.config.Issuer),
+		jwt.WithAudience(a.config.Audience),
+		// Pin the accepted signing algorithm explicitly rather than relying
+		// on keyFunc's rsa.PublicKey return type to incidentally reject an
+		// HMAC algorithm-confusion attempt.
+		jwt.WithValidMethods([]string{"RS256"}),
+	)
+	if err != nil || !parsed.Valid {
+		return ctx, true, apierrors.ErrUnauthenticated(fmt.Sprintf("invalid m2m token: %v", err))
+	}
+	validClaims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return ctx, true, apierrors.ErrUnauthenticated("invalid m2m token claims")
+	}
+
+	subject, _ := validClaims.GetSubject()
+	if len(a.config.AllowedSubjects) > 0 && !containsString(a.config.AllowedSubjects, subject) {
+		return ctx, true, apierrors.ErrUnauthenticated(fmt.Sprintf("subject %q is not allowed", subject))
+	}
+
+	scopes := scopesFromClaims(validClaims)
+	if required, ok := a.config.MethodScopes[fullMethod]; ok && !intersects(scopes, required) {
+		return ctx, true, apierrors.ErrUnauthenticated(fmt.Sprintf("m2m token missing required scope for %s", fullMethod))
+	}
+
+	if retryAfterMs, limited := a.rateLimit(subject); limited {
+		return ctx, true, apierrors.ErrRateLimited(retryAfterMs)
+	}
+
+	ctx = context.WithValue(ctx, m2mSubjectContextKey, subject)
+	ctx = context.WithValue(ctx, m2mScopesContextKey, scopes)
+	if holder, ok := authModeHolderFromContext(ctx); ok {
+		holder.mode = "jwt"
+	}
+	return ctx, true, nil
+}
+
+// rateLimit enforces RateLimitPerSecond per authenticated `sub`, so a single
+// misbehaving or compromised M2M caller can't exhaust downstream capacity
+// for every other indexer/delivery worker sharing the node. It reports
+// (retryAfterMs, true) when the caller should be rejected, rather than
+// blocking until a token is available — authenticate is on the request
+// path and must not stall it.
+func (a *JWTAuthorizer) rateLimit(subject string) (retryAfterMs int64, limited bool) {
+	if a.config.RateLimitPerSecond <= 0 {
+		return 0, false
+	}
+
+	a.rlMu.Lock()
+	limiter, ok := a.limiters[subject]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(a.config.RateLimitPerSecond), a.config.RateLimitBurst)
+		a.limiters[subject] = limiter
+	}
+	a.rlMu.Unlock()
+
+	reservation := limiter.Reserve()
+	if !reservation.OK() {
+		return 0, true
+	}
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return delay.Milliseconds(), true
+	}
+	return 0, false
+}
+
+// keyFunc resolves the RSA public key for a token's `kid`, refreshing the
+// cached JWKS on a miss.
+func (a *JWTAuthorizer) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token is missing kid header")
+	}
+
+	if key := a.cachedKey(kid); key != nil {
+		return key, nil
+	}
+	if err := a.refreshKeys(); err != nil {
+		return nil, errors.Wrap(err, "refreshing jwks")
+	}
+	if key := a.cachedKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, errors.Errorf("no jwks key found for kid %q", kid)
+}
+
+func (a *JWTAuthorizer) cachedKey(kid string) *rsa.PublicKey {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if time.Since(a.fetchedAt) > a.config.KeyCacheTTL {
+		return nil
+	}
+	return a.keys[kid]
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// refreshKeys fetches the JWKS, debounced to at most once per
+// minJWKSRefreshInterval so a kid cache-miss flood can't force a fetch per
+// request. It only holds a.mu to check/claim the debounce window and to
+// publish the result; the HTTP round-trip itself runs unlocked so a slow
+// JWKS endpoint doesn't serialize every other request behind it.
+func (a *JWTAuthorizer) refreshKeys() error {
+	a.mu.Lock()
+	if time.Since(a.lastRefreshAttempt) < minJWKSRefreshInterval {
+		a.mu.Unlock()
+		return nil
+	}
+	a.lastRefreshAttempt = time.Now()
+	a.mu.Unlock()
+
+	resp, err := a.client.Get(a.config.JWKSURL)
+	if err != nil {
+		return errors.Wrap(err, "fetching jwks")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("fetching jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var parsed jwks
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return errors.Wrap(err, "decoding jwks")
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(parsed.Keys))
+	for _, key := range parsed.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		pubKey, err := rsaPublicKeyFromJWK(key)
+		if err != nil {
+			if a.log != nil {
+				a.log.Warn("skipping malformed jwks key", zap.String("kid", key.Kid), zap.Error(err))
+			}
+			continue
+		}
+		keys[key.Kid] = pubKey
+	}
+
+	a.mu.Lock()
+	a.keys = keys
+	a.fetchedAt = time.Now()
+	a.mu.Unlock()
+	return nil
+}
+
+func rsaPublicKeyFromJWK(key jwk) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding modulus")
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding exponent")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(new(big.Int).SetBytes(eBytes).Int64()),
+	}, nil
+}
+
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	values := md.Get(authorizationMetadataKey)
+	if len(values) == 0 {
+		return "", false
+	}
+	const prefix = "bearer "
+	value := values[0]
+	if len(value) <= len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+		return "", false
+	}
+	return value[len(prefix):], true
+}
+
+func scopesFromClaims(claims jwt.MapClaims) []string {
+	switch scope := claims["scope"].(type) {
+	case string:
+		return strings.Fields(scope)
+	case []interface{}:
+		scopes := make([]string, 0, len(scope))
+		for _, s := range scope {
+			if str, ok := s.(string); ok {
+				scopes = append(scopes, str)
+			}
+		}
+		return scopes
+	default:
+		return nil
+	}
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		if containsString(b, x) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,340 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	apierrors "github.com/xmtp/xmtp-node-go/pkg/api/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newTestJWKSServer(t *testing.T, kid string, pub *rsa.PublicKey) *httptest.Server {
+	t.Helper()
+
+	body, err := json.Marshal(jwks{Keys: []jwk{{
+		Kid: kid,
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+	if err != nil {
+		t.Fatalf("marshaling jwks: %v", err)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+}
+
+func signTestToken(t *testing.T, priv *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(priv)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return signed
+}
+
+func incomingCtxWithBearer(token string) context.Context {
+	md := metadata.Pairs(authorizationMetadataKey, "Bearer "+token)
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+const testMethod = "/xmtp.message_api.v3.MlsApi/PublishToGroup"
+
+func TestJWTAuthorizerAuthenticate(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating test key: %v", err)
+	}
+	const kid = "test-kid"
+	jwksServer := newTestJWKSServer(t, kid, &priv.PublicKey)
+	defer jwksServer.Close()
+
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		base := jwt.MapClaims{
+			"iss":   "https://m2m.example.com/",
+			"aud":   "xmtp-node",
+			"sub":   "indexer-1",
+			"scope": "message:publish",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			base[k] = v
+		}
+		return base
+	}
+
+	newAuthorizer := func(methodScopes map[string][]string, allowedSubjects []string) *JWTAuthorizer {
+		return NewJWTAuthorizer(&JWTAuthnConfig{
+			Issuer:          "https://m2m.example.com/",
+			Audience:        "xmtp-node",
+			JWKSURL:         jwksServer.URL,
+			AllowedSubjects: allowedSubjects,
+			MethodScopes:    methodScopes,
+			Log:             zap.NewNop(),
+		})
+	}
+
+	t.Run("no token falls through unhandled", func(t *testing.T) {
+		_, handled, err := newAuthorizer(nil, nil).authenticate(context.Background(), testMethod)
+		if handled || err != nil {
+			t.Fatalf("expected unhandled/nil, got handled=%v err=%v", handled, err)
+		}
+	})
+
+	t.Run("token from a different issuer falls through unhandled", func(t *testing.T) {
+		token := signTestToken(t, priv, kid, claims(jwt.MapClaims{"iss": "https://someone-else.example.com/"}))
+		_, handled, err := newAuthorizer(nil, nil).authenticate(incomingCtxWithBearer(token), testMethod)
+		if handled || err != nil {
+			t.Fatalf("expected unhandled/nil, got handled=%v err=%v", handled, err)
+		}
+	})
+
+	t.Run("valid token is authenticated and populates the context", func(t *testing.T) {
+		token := signTestToken(t, priv, kid, claims(nil))
+		ctx, handled, err := newAuthorizer(nil, nil).authenticate(incomingCtxWithBearer(token), testMethod)
+		if !handled || err != nil {
+			t.Fatalf("expected authenticated, got handled=%v err=%v", handled, err)
+		}
+		if subject, ok := M2MSubject(ctx); !ok || subject != "indexer-1" {
+			t.Fatalf("expected subject indexer-1, got %q (ok=%v)", subject, ok)
+		}
+	})
+
+	t.Run("expired token for the configured issuer is rejected, not passed through", func(t *testing.T) {
+		token := signTestToken(t, priv, kid, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}))
+		_, handled, err := newAuthorizer(nil, nil).authenticate(incomingCtxWithBearer(token), testMethod)
+		if !handled || err == nil {
+			t.Fatalf("expected a rejection, got handled=%v err=%v", handled, err)
+		}
+	})
+
+	t.Run("subject outside the allow-list is rejected", func(t *testing.T) {
+		token := signTestToken(t, priv, kid, claims(nil))
+		_, handled, err := newAuthorizer(nil, []string{"someone-else"}).authenticate(incomingCtxWithBearer(token), testMethod)
+		if !handled || err == nil {
+			t.Fatalf("expected a rejection, got handled=%v err=%v", handled, err)
+		}
+	})
+
+	t.Run("missing required scope for the method is rejected", func(t *testing.T) {
+		token := signTestToken(t, priv, kid, claims(jwt.MapClaims{"scope": "message:publish"}))
+		methodScopes := map[string][]string{testMethod: {"mls:write"}}
+		_, handled, err := newAuthorizer(methodScopes, nil).authenticate(incomingCtxWithBearer(token), testMethod)
+		if !handled || err == nil {
+			t.Fatalf("expected a rejection, got handled=%v err=%v", handled, err)
+		}
+	})
+
+	t.Run("a subject over its rate limit is rejected with ErrRateLimited", func(t *testing.T) {
+		a := NewJWTAuthorizer(&JWTAuthnConfig{
+			Issuer:             "https://m2m.example.com/",
+			Audience:           "xmtp-node",
+			JWKSURL:            jwksServer.URL,
+			RateLimitPerSecond: 1,
+			RateLimitBurst:     1,
+			Log:                zap.NewNop(),
+		})
+		token := signTestToken(t, priv, kid, claims(nil))
+
+		if _, handled, err := a.authenticate(incomingCtxWithBearer(token), testMethod); !handled || err != nil {
+			t.Fatalf("expected the first request within burst to be authenticated, got handled=%v err=%v", handled, err)
+		}
+
+		_, handled, err := a.authenticate(incomingCtxWithBearer(token), testMethod)
+		if !handled || err == nil {
+			t.Fatalf("expected the second request to be rate limited, got handled=%v err=%v", handled, err)
+		}
+		st, ok := status.FromError(err)
+		if !ok || st.Code() != codes.ResourceExhausted {
+			t.Fatalf("expected a ResourceExhausted status, got %v", err)
+		}
+		if reason, _, ok := apierrors.ReasonFromStatus(st); !ok || reason != "RATE_LIMITED" {
+			t.Fatalf("expected reason RATE_LIMITED, got %q (ok=%v)", reason, ok)
+		}
+	})
+}
+
+// TestJWTAuthorizerUnaryWithFallbackRejectsUnrecognizedTokens guards against
+// the JWT-only-mode auth bypass: with no wallet fallback configured, a
+// request without a recognized M2M token must be rejected, not forwarded to
+// the handler unauthenticated.
+func TestJWTAuthorizerUnaryWithFallbackRejectsUnrecognizedTokens(t *testing.T) {
+	a := NewJWTAuthorizer(&JWTAuthnConfig{
+		Issuer:   "https://m2m.example.com/",
+		Audience: "xmtp-node",
+		JWKSURL:  "http://127.0.0.1:0",
+		Log:      zap.NewNop(),
+	})
+	info := &grpc.UnaryServerInfo{FullMethod: testMethod}
+
+	t.Run("no fallback rejects outright", func(t *testing.T) {
+		handlerCalled := false
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			handlerCalled = true
+			return nil, nil
+		}
+
+		_, err := a.UnaryWithFallback(nil)(context.Background(), nil, info, handler)
+		if err == nil {
+			t.Fatal("expected an error rejecting the unrecognized request")
+		}
+		if handlerCalled {
+			t.Fatal("handler must not run for an unrecognized token in JWT-only mode")
+		}
+	})
+
+	t.Run("with a fallback, delegates entirely to it", func(t *testing.T) {
+		handlerCalled := false
+		fallbackCalled := false
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			handlerCalled = true
+			return nil, nil
+		}
+		fallback := func(
+			ctx context.Context,
+			req interface{},
+			info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			fallbackCalled = true
+			return handler(ctx, req)
+		}
+
+		_, err := a.UnaryWithFallback(fallback)(context.Background(), nil, info, handler)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !fallbackCalled || !handlerCalled {
+			t.Fatalf("expected fallback and handler to run, fallbackCalled=%v handlerCalled=%v", fallbackCalled, handlerCalled)
+		}
+	})
+}
+
+// TestAuthModeJWTThroughInterceptorChain drives an authenticated M2M request
+// through TelemetryInterceptor and JWTAuthorizer chained the same way
+// Server.startGRPC chains them (telemetry wrapping the authorizer, so it
+// still sees rejected requests — see the sibling test below) and asserts the
+// logged auth_mode is "jwt". JWTAuthorizer reports that back to the outer
+// TelemetryInterceptor via the authModeHolder stashed in ctx, since
+// telemetry's own ctx variable never observes the child ctx the authorizer
+// derives for the handler.
+func TestAuthModeJWTThroughInterceptorChain(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating test key: %v", err)
+	}
+	const kid = "test-kid"
+	jwksServer := newTestJWKSServer(t, kid, &priv.PublicKey)
+	defer jwksServer.Close()
+
+	a := NewJWTAuthorizer(&JWTAuthnConfig{
+		Issuer:   "https://m2m.example.com/",
+		Audience: "xmtp-node",
+		JWKSURL:  jwksServer.URL,
+		Log:      zap.NewNop(),
+	})
+
+	core, logs := observer.New(zap.DebugLevel)
+	ti := NewTelemetryInterceptor(zap.New(core))
+
+	chained := grpc_middleware.ChainUnaryServer(ti.Unary(), a.UnaryWithFallback(nil))
+
+	token := signTestToken(t, priv, kid, jwt.MapClaims{
+		"iss":   "https://m2m.example.com/",
+		"aud":   "xmtp-node",
+		"sub":   "indexer-1",
+		"scope": "message:publish",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: testMethod}
+
+	if _, err := chained(incomingCtxWithBearer(token), nil, info, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries := logs.FilterMessage("api request").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected exactly one logged request, got %d", len(entries))
+	}
+	authMode, ok := entries[0].ContextMap()["auth_mode"]
+	if !ok || authMode != "jwt" {
+		t.Fatalf("expected auth_mode=jwt, got %v (ok=%v)", authMode, ok)
+	}
+}
+
+// TestAuthModeRejectedRequestIsStillLogged guards against the opposite
+// regression: TelemetryInterceptor must wrap JWTAuthorizer, not run behind
+// it, or a request the authorizer rejects (here, an expired M2M token)
+// returns before record() ever runs and its "api request" log line and
+// metric silently disappear — exactly the failure traffic operators need to
+// alert on.
+func TestAuthModeRejectedRequestIsStillLogged(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating test key: %v", err)
+	}
+	const kid = "test-kid"
+	jwksServer := newTestJWKSServer(t, kid, &priv.PublicKey)
+	defer jwksServer.Close()
+
+	a := NewJWTAuthorizer(&JWTAuthnConfig{
+		Issuer:   "https://m2m.example.com/",
+		Audience: "xmtp-node",
+		JWKSURL:  jwksServer.URL,
+		Log:      zap.NewNop(),
+	})
+
+	core, logs := observer.New(zap.DebugLevel)
+	ti := NewTelemetryInterceptor(zap.New(core))
+
+	chained := grpc_middleware.ChainUnaryServer(ti.Unary(), a.UnaryWithFallback(nil))
+
+	expiredToken := signTestToken(t, priv, kid, jwt.MapClaims{
+		"iss": "https://m2m.example.com/",
+		"aud": "xmtp-node",
+		"sub": "indexer-1",
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	})
+	handlerCalled := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handlerCalled = true
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: testMethod}
+
+	if _, err := chained(incomingCtxWithBearer(expiredToken), nil, info, handler); err == nil {
+		t.Fatal("expected the expired token to be rejected")
+	}
+	if handlerCalled {
+		t.Fatal("handler must not run for a rejected token")
+	}
+
+	entries := logs.FilterMessage("api request").All()
+	if len(entries) != 1 {
+		t.Fatalf("expected the rejected request to still be logged exactly once, got %d", len(entries))
+	}
+}
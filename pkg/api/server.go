@@ -19,6 +19,8 @@ import (
 	messagev1openapi "github.com/xmtp/proto/v3/openapi/message_api/v1"
 	"github.com/xmtp/xmtp-node-go/pkg/ratelimiter"
 	"github.com/xmtp/xmtp-node-go/pkg/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"google.golang.org/grpc/health"
 	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
 
@@ -42,14 +44,17 @@ var (
 type Server struct {
 	*Config
 
-	grpcListener net.Listener
-	httpListener net.Listener
-	messagev1    *messagev1.Service
-	messagev3    *messagev3.Service
-	wg           sync.WaitGroup
-	ctx          context.Context
-
-	authorizer *WalletAuthorizer
+	grpcListener  net.Listener
+	httpListener  net.Listener
+	debugListener net.Listener
+	grpcServer    *grpc.Server
+	messagev1     *messagev1.Service
+	messagev3     *messagev3.Service
+	wg            sync.WaitGroup
+	ctx           context.Context
+
+	authorizer    *WalletAuthorizer
+	jwtAuthorizer *JWTAuthorizer
 }
 
 func New(config *Config) (*Server, error) {
@@ -75,6 +80,12 @@ func New(config *Config) (*Server, error) {
 		return nil, err
 	}
 
+	// Start the debug/introspection HTTP server, if enabled.
+	err = s.startDebug()
+	if err != nil {
+		return nil, err
+	}
+
 	return s, nil
 }
 
@@ -89,9 +100,16 @@ func (s *Server) startGRPC() error {
 	prometheusOnce.Do(func() {
 		prometheus.EnableHandlingTimeHistogram()
 	})
-	unary := []grpc.UnaryServerInterceptor{prometheus.UnaryServerInterceptor}
-	stream := []grpc.StreamServerInterceptor{prometheus.StreamServerInterceptor}
-
+	unary := []grpc.UnaryServerInterceptor{prometheus.UnaryServerInterceptor, otelgrpc.UnaryServerInterceptor()}
+	stream := []grpc.StreamServerInterceptor{prometheus.StreamServerInterceptor, otelgrpc.StreamServerInterceptor()}
+
+	// TelemetryInterceptor wraps the authorizers (not the reverse) so it
+	// still logs + EmitAPIRequest's requests WalletAuthorizer/JWTAuthorizer
+	// themselves reject (rate-limited, bad signature, expired/invalid JWT)
+	// — exactly the traffic operators alert on. It reports auth_mode via a
+	// mutable holder threaded through ctx rather than reading the
+	// authorizers' derived ctx back out, since that ctx never reaches this
+	// outer frame. See authModeHolder.
 	telemetryInterceptor := NewTelemetryInterceptor(s.Log)
 	unary = append(unary, telemetryInterceptor.Unary())
 	stream = append(stream, telemetryInterceptor.Stream())
@@ -109,10 +127,43 @@ func (s *Server) startGRPC() error {
 			AllowLister:  s.Config.AllowLister,
 			Log:          s.Log.Named("authn"),
 		})
+	}
+
+	if s.Config.Authn.JWT.Enable {
+		s.jwtAuthorizer = NewJWTAuthorizer(&JWTAuthnConfig{
+			Issuer:          s.Config.Authn.JWT.Issuer,
+			Audience:        s.Config.Authn.JWT.Audience,
+			JWKSURL:         s.Config.Authn.JWT.JWKSURL,
+			AllowedSubjects: s.Config.Authn.JWT.AllowedSubjects,
+			MethodScopes:    s.Config.Authn.JWT.MethodScopes,
+			KeyCacheTTL:     s.Config.Authn.JWT.KeyCacheTTL,
+			Log:             s.Log.Named("authn-jwt"),
+		})
+	}
+
+	switch {
+	case s.jwtAuthorizer != nil && s.authorizer != nil:
+		// JWTAuthorizer short-circuits ahead of WalletAuthorizer: an M2M
+		// token is authenticated (or rejected) entirely on its own, and
+		// anything else falls through to the wallet-signature check.
+		unary = append(unary, s.jwtAuthorizer.UnaryWithFallback(s.authorizer.Unary()))
+		stream = append(stream, s.jwtAuthorizer.StreamWithFallback(s.authorizer.Stream()))
+	case s.jwtAuthorizer != nil:
+		unary = append(unary, s.jwtAuthorizer.UnaryWithFallback(nil))
+		stream = append(stream, s.jwtAuthorizer.StreamWithFallback(nil))
+	case s.authorizer != nil:
 		unary = append(unary, s.authorizer.Unary())
 		stream = append(stream, s.authorizer.Stream())
 	}
 
+	// Innermost: converts errors from the handler into structured XMTP
+	// statuses before they bubble up to TelemetryInterceptor, so it logs
+	// the typed reason rather than a bare gRPC code. Errors returned by the
+	// authorizers above don't pass through it.
+	errorInterceptor := NewErrorInterceptor()
+	unary = append(unary, errorInterceptor.Unary())
+	stream = append(stream, errorInterceptor.Stream())
+
 	options := []grpc.ServerOption{
 		grpc.Creds(insecure.NewCredentials()),
 		grpc.UnaryInterceptor(middleware.ChainUnaryServer(unary...)),
@@ -120,6 +171,7 @@ func (s *Server) startGRPC() error {
 		grpc.MaxRecvMsgSize(s.Config.Options.MaxMsgSize),
 	}
 	grpcServer := grpc.NewServer(options...)
+	s.grpcServer = grpcServer
 	healthcheck := health.NewServer()
 	healthgrpc.RegisterHealthServer(grpcServer, healthcheck)
 
@@ -196,10 +248,12 @@ func (s *Server) startHTTP() error {
 		return errors.Wrap(err, "creating grpc-gateway listener")
 	}
 
-	// Add two handler wrappers to mux: gzipWrapper and allowCORS
+	// Add handler wrappers to mux: otelhttp, gzipWrapper and allowCORS.
+	// otelhttp goes outermost so every request gets a span, including ones
+	// served directly by this mux (Swagger UI, etc.) rather than proxied.
 	server := http.Server{
 		Addr:    addr,
-		Handler: allowCORS(gzipWrapper(mux)),
+		Handler: otelhttp.NewHandler(allowCORS(gzipWrapper(mux)), "grpc-gateway"),
 	}
 
 	tracing.GoPanicWrap(s.ctx, &s.wg, "http", func(ctx context.Context) {
@@ -233,6 +287,13 @@ func (s *Server) Close() {
 		}
 	}
 
+	if s.debugListener != nil {
+		err := s.debugListener.Close()
+		if err != nil {
+			s.Log.Error("closing debug listener", zap.Error(err))
+		}
+	}
+
 	s.wg.Wait()
 	s.Log.Info("closed")
 }
@@ -247,6 +308,12 @@ func (s *Server) dialGRPC(ctx context.Context) (*grpc.ClientConn, error) {
 		grpc.WithDefaultCallOptions(
 			grpc.MaxCallRecvMsgSize(s.Config.Options.MaxMsgSize),
 		),
+		// Propagates the span started by the otelhttp middleware in startHTTP
+		// onto this internal grpc-gateway -> grpc call, so the two hops share
+		// a trace, and rehydrates structured XMTP errors attached by
+		// ErrorInterceptor back into rich Go errors for the gateway loop.
+		grpc.WithChainUnaryInterceptor(otelgrpc.UnaryClientInterceptor(), unwrapErrorsUnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(otelgrpc.StreamClientInterceptor(), unwrapErrorsStreamClientInterceptor),
 	)
 }
 
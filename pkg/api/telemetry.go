@@ -4,8 +4,11 @@ import (
 	"context"
 	"strings"
 
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	apierrors "github.com/xmtp/xmtp-node-go/pkg/api/errors"
 	messagev1 "github.com/xmtp/xmtp-node-go/pkg/api/message/v1"
 	"github.com/xmtp/xmtp-node-go/pkg/metrics"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
@@ -30,8 +33,9 @@ func (ti *TelemetryInterceptor) Unary() grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
+		ctx, holder := withAuthModeHolder(ctx)
 		res, err := handler(ctx, req)
-		ti.record(ctx, info.FullMethod, err)
+		ti.record(ctx, holder, info.FullMethod, err)
 		return res, err
 	}
 }
@@ -43,13 +47,16 @@ func (ti *TelemetryInterceptor) Stream() grpc.StreamServerInterceptor {
 		info *grpc.StreamServerInfo,
 		handler grpc.StreamHandler,
 	) error {
-		res := handler(srv, stream)
-		ti.record(stream.Context(), info.FullMethod, nil)
-		return res
+		ctx, holder := withAuthModeHolder(stream.Context())
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = ctx
+		err := handler(srv, wrapped)
+		ti.record(ctx, holder, info.FullMethod, err)
+		return err
 	}
 }
 
-func (ti *TelemetryInterceptor) record(ctx context.Context, fullMethod string, err error) {
+func (ti *TelemetryInterceptor) record(ctx context.Context, holder *authModeHolder, fullMethod string, err error) {
 	serviceName, methodName := splitMethodName(fullMethod)
 	ri := messagev1.NewRequesterInfo(ctx)
 	fields := append(
@@ -60,6 +67,14 @@ func (ti *TelemetryInterceptor) record(ctx context.Context, fullMethod string, e
 		ri.ZapFields()...,
 	)
 
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields,
+			zap.String("trace_id", sc.TraceID().String()),
+			zap.String("span_id", sc.SpanID().String()))
+	}
+
+	fields = append(fields, zap.String("auth_mode", authMode(ctx, holder)))
+
 	md, _ := metadata.FromIncomingContext(ctx)
 	if ips := md.Get("x-forwarded-for"); len(ips) > 0 {
 		// There are potentially multiple comma separated IPs bundled in that first value
@@ -80,12 +95,70 @@ func (ti *TelemetryInterceptor) record(ctx context.Context, fullMethod string, e
 				zap.String("error_message", grpcErr.Message()),
 			}...)
 		}
+		// Use the *status.Status, not err itself: by the time record runs,
+		// err has usually passed through ErrorInterceptor, which replaces
+		// the original XMTPError with a plain gRPC status error. The
+		// ErrorInfo detail survives on the status either way.
+		if reason, metadata, ok := apierrors.ReasonFromStatus(grpcErr); ok {
+			fields = append(fields, zap.String("error_reason", reason))
+			for k, v := range metadata {
+				fields = append(fields, zap.String("error_detail_"+k, v))
+			}
+		}
 	}
 
 	logFn("api request", fields...)
 	metrics.EmitAPIRequest(ctx, fields)
 }
 
+// authModeContextKey is the context key under which TelemetryInterceptor
+// stashes an authModeHolder before invoking the rest of the interceptor
+// chain.
+type authModeContextKey struct{}
+
+// authModeHolder is a mutable slot TelemetryInterceptor injects into the ctx
+// ahead of the authorizers and reads back after the handler returns.
+// TelemetryInterceptor has to wrap the authorizers (not run behind them) so
+// that it still logs+EmitAPIRequest's requests the authorizers themselves
+// reject; that means its own ctx variable never sees the child ctx an
+// authorizer derives for the handler (e.g. JWTAuthorizer's M2M-subject
+// value). Routing the authenticated mode through a shared pointer, rather
+// than a ctx value, lets JWTAuthorizer report "jwt" without requiring
+// TelemetryInterceptor to run inside it.
+type authModeHolder struct {
+	mode string
+}
+
+// withAuthModeHolder returns a ctx carrying a fresh authModeHolder, and the
+// holder itself for the caller to read back later.
+func withAuthModeHolder(ctx context.Context) (context.Context, *authModeHolder) {
+	holder := &authModeHolder{}
+	return context.WithValue(ctx, authModeContextKey{}, holder), holder
+}
+
+// authModeHolderFromContext returns the authModeHolder TelemetryInterceptor
+// stashed in ctx, if any.
+func authModeHolderFromContext(ctx context.Context) (*authModeHolder, bool) {
+	holder, ok := ctx.Value(authModeContextKey{}).(*authModeHolder)
+	return holder, ok
+}
+
+// authMode reports how this request was authenticated, so operators can
+// tell M2M and wallet traffic apart in logs/metrics. JWTAuthorizer sets
+// holder.mode to "jwt" once it authenticates an M2M token; any other request
+// that carried an authorization header is reported as "wallet", whether or
+// not WalletAuthorizer checked it (an M2M token JWTAuthorizer rejected
+// included); a request without one is "none".
+func authMode(ctx context.Context, holder *authModeHolder) string {
+	if holder != nil && holder.mode != "" {
+		return holder.mode
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(authorizationMetadataKey)) > 0 {
+		return "wallet"
+	}
+	return "none"
+}
+
 func splitMethodName(fullMethodName string) (serviceName string, methodName string) {
 	fullMethodName = strings.TrimPrefix(fullMethodName, "/") // remove leading slash
 	if i := strings.Index(fullMethodName, "/"); i >= 0 {
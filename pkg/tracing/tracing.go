@@ -1,18 +1,42 @@
-// Package tracing enables [Datadog APM tracing](https://docs.datadoghq.com/tracing/) capabilities,
-// focusing specifically on [Error Tracking](https://docs.datadoghq.com/tracing/error_tracking/)
+// Package tracing provides vendor-neutral distributed tracing for xmtp-node,
+// built on [OpenTelemetry]. Spans are exported over OTLP/gRPC or OTLP/HTTP
+// depending on configuration (OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_SERVICE_NAME,
+// OTEL_TRACES_SAMPLER), with [Datadog APM] retained as a drop-in exporter for
+// backward compatibility (set DD_TRACE_ENABLED=true).
+//
+// [OpenTelemetry]: https://opentelemetry.io
+// [Datadog APM]: https://docs.datadoghq.com/tracing/
 package tracing
 
 import (
 	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	ddotel "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentelemetry"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"go.uber.org/zap"
-	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 )
 
+// tracerName identifies this package as the instrumentation library that
+// produced a span, per the OpenTelemetry tracer naming convention.
+const tracerName = "github.com/xmtp/xmtp-node-go"
+
 var (
-	// reimport relevant bits of the tracer API
-	StartSpanFromContext = tracer.StartSpanFromContext
-	WithError            = tracer.WithError
+	tracer   = otel.Tracer(tracerName)
+	shutdown func(context.Context) error
 )
 
 type logger struct{ *zap.Logger }
@@ -21,33 +45,84 @@ func (l logger) Log(msg string) {
 	l.Error(msg)
 }
 
-// Start boots the datadog tracer, run this once early in the startup sequence.
+// Start boots the tracer, run this once early in the startup sequence.
+//
+// By default this configures an OTLP exporter (gRPC, or HTTP if
+// OTEL_EXPORTER_OTLP_ENDPOINT begins with http(s)://). Setting
+// DD_TRACE_ENABLED=true instead routes spans through Datadog APM via its
+// OpenTelemetry bridge, for environments that haven't migrated collectors yet.
 func Start(l *zap.Logger) {
-	tracer.Start(tracer.WithService("xmtp-node"), tracer.WithLogger(logger{l}))
+	ctx := context.Background()
+
+	if ddTraceEnabled() {
+		provider := ddotel.NewTracerProvider(ddotel.WithLogger(logger{l}))
+		otel.SetTracerProvider(provider)
+		tracer = provider.Tracer(tracerName)
+		shutdown = func(context.Context) error {
+			_, err := provider.Shutdown()
+			return err
+		}
+		l.Info("tracing started", zap.String("exporter", "datadog"))
+		return
+	}
+
+	exporter, err := newOTLPExporter(ctx)
+	if err != nil {
+		l.Error("starting otel exporter, spans will not be recorded", zap.Error(err))
+		return
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceNameKey.String(serviceName())),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		l.Error("building otel resource", zap.Error(err))
+		res = resource.Default()
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(samplerFromEnv(l)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	tracer = provider.Tracer(tracerName)
+	shutdown = provider.Shutdown
+
+	l.Info("tracing started",
+		zap.String("exporter", exporterKind()),
+		zap.String("endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")))
 }
 
-// Stop shuts down the datadog tracer, defer this right after Start().
+// Stop shuts down the tracer, defer this right after Start().
 func Stop() {
-	tracer.Stop()
+	if shutdown == nil {
+		return
+	}
+	_ = shutdown(context.Background())
 }
 
 // Do executes action in the context of a top level span,
 // tagging the span with the error if the action panics.
-// This should trigger DD APM's Error Tracking to record the error.
 func Do(ctx context.Context, spanName string, action func(context.Context)) {
-	span, ctx := tracer.StartSpanFromContext(ctx, spanName)
+	ctx, span := tracer.Start(ctx, spanName)
 	defer func() {
 		r := recover()
 		switch r := r.(type) {
 		case error:
 			// If action panics with an error,
 			// finish the span with the error.
-			span.Finish(WithError(r))
+			span.RecordError(r)
+			span.SetStatus(codes.Error, r.Error())
 		default:
 			// This is the normal non-panicking path
 			// as well as path with panics that don't have an error.
-			span.Finish()
 		}
+		span.End()
 		if r != nil {
 			// Repanic so that we don't suppress normal panic behavior.
 			panic(r)
@@ -56,10 +131,87 @@ func Do(ctx context.Context, spanName string, action func(context.Context)) {
 	action(ctx)
 }
 
-// Link connects a logger to a particular trace and span.
-// DD APM should provide some additional functionality based on that.
-func Link(span tracer.Span, l *zap.Logger) *zap.Logger {
+// GoPanicWrap runs action in a new goroutine tracked by wg, under its own top
+// level span named name, recovering and re-panicking as Do does.
+func GoPanicWrap(ctx context.Context, wg *sync.WaitGroup, name string, action func(context.Context)) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		Do(ctx, name, action)
+	}()
+}
+
+// Link connects a logger to a particular trace and span, so that log lines
+// emitted through it can be correlated with the trace backend in use.
+func Link(span trace.Span, l *zap.Logger) *zap.Logger {
+	sc := span.SpanContext()
 	return l.With(
-		zap.Uint64("dd.trace_id", span.Context().TraceID()),
-		zap.Uint64("dd.span_id", span.Context().SpanID()))
-}
\ No newline at end of file
+		zap.String("trace_id", sc.TraceID().String()),
+		zap.String("span_id", sc.SpanID().String()))
+}
+
+func ddTraceEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("DD_TRACE_ENABLED"))
+	return enabled
+}
+
+func serviceName() string {
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
+	}
+	return "xmtp-node"
+}
+
+func exporterKind() string {
+	if isHTTPEndpoint(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")) {
+		return "otlp/http"
+	}
+	return "otlp/grpc"
+}
+
+func isHTTPEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://")
+}
+
+func newOTLPExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if isHTTPEndpoint(endpoint) {
+		return otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithInsecure()}
+	if endpoint != "" {
+		opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+// samplerFromEnv implements the subset of the OTEL_TRACES_SAMPLER spec that
+// we need in practice, defaulting to always-on so traces aren't silently
+// dropped by a misconfigured environment.
+func samplerFromEnv(l *zap.Logger) sdktrace.Sampler {
+	switch os.Getenv("OTEL_TRACES_SAMPLER") {
+	case "", "always_on":
+		return sdktrace.AlwaysSample()
+	case "always_off":
+		return sdktrace.NeverSample()
+	case "traceidratio", "parentbased_traceidratio":
+		ratio, err := strconv.ParseFloat(os.Getenv("OTEL_TRACES_SAMPLER_ARG"), 64)
+		if err != nil {
+			l.Warn("invalid OTEL_TRACES_SAMPLER_ARG, defaulting to 1.0", zap.Error(err))
+			ratio = 1.0
+		}
+		if os.Getenv("OTEL_TRACES_SAMPLER") == "traceidratio" {
+			return sdktrace.TraceIDRatioBased(ratio)
+		}
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+	case "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample())
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample())
+	default:
+		l.Warn("unrecognized OTEL_TRACES_SAMPLER, defaulting to always_on",
+			zap.String("value", os.Getenv("OTEL_TRACES_SAMPLER")))
+		return sdktrace.AlwaysSample()
+	}
+}